@@ -0,0 +1,124 @@
+package grpc_health_check
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/collector/config/configgrpc"
+
+	"github.com/jammymalina/otel-grpc-healthceck/probe"
+)
+
+// AggregationPolicy determines how a service's probes are combined into a
+// single serving status.
+type AggregationPolicy string
+
+const (
+	// PolicyAll requires every one of a service's probes to be healthy.
+	PolicyAll AggregationPolicy = "all"
+	// PolicyAny requires at least one of a service's probes to be healthy.
+	PolicyAny AggregationPolicy = "any"
+	// PolicyQuorum requires at least Quorum of a service's probes to be
+	// healthy.
+	PolicyQuorum AggregationPolicy = "quorum"
+)
+
+// ServiceConfig describes a gRPC health service name and how its status is
+// aggregated from one or more probes.
+type ServiceConfig struct {
+	// Name is the gRPC health service name reported through SetServingStatus
+	// and queried by clients via Check/Watch. The empty string is the
+	// overall server status.
+	Name string `mapstructure:"name"`
+
+	// Probes lists the names of the probes (from Config.Probes) that back
+	// this service. If empty, the service aggregates every configured
+	// probe - this is how the empty-string "" service typically reports
+	// overall server status.
+	Probes []string `mapstructure:"probes"`
+
+	Policy AggregationPolicy `mapstructure:"policy"`
+	// Quorum is the number of healthy probes required when Policy is
+	// PolicyQuorum.
+	Quorum int `mapstructure:"quorum"`
+}
+
+func (s *ServiceConfig) Validate(totalProbes int) error {
+	numProbes := len(s.Probes)
+	if numProbes == 0 {
+		numProbes = totalProbes
+	}
+	switch s.Policy {
+	case PolicyAll, PolicyAny:
+	case PolicyQuorum:
+		if s.Quorum <= 0 || s.Quorum > numProbes {
+			return fmt.Errorf("quorum must be between 1 and %d", numProbes)
+		}
+	default:
+		return fmt.Errorf("unknown policy %q", s.Policy)
+	}
+	return nil
+}
+
+// Config is the configuration for the grpc_health_check extension.
+type Config struct {
+	Grpc configgrpc.ServerConfig `mapstructure:"grpc"`
+
+	// Probes lists the individual upstream health checks available to be
+	// referenced by Services.
+	Probes []probe.Config `mapstructure:"probes"`
+
+	// Services lists the gRPC health service names this extension reports
+	// on, each an aggregation over one or more Probes. At least one entry
+	// is required.
+	Services []ServiceConfig `mapstructure:"services"`
+
+	// DrainPeriod is how long Shutdown waits after flipping all services to
+	// NOT_SERVING before calling GracefulStop, giving load balancers and
+	// Watch() subscribers time to stop routing traffic here.
+	DrainPeriod time.Duration `mapstructure:"drain_period"`
+
+	// AdminEndpoint, if set, serves a small HTTP API for forcing an
+	// out-of-band re-probe: POST /probes/{name}/check runs the named
+	// probe immediately and responds with its resulting status.
+	AdminEndpoint string `mapstructure:"admin_endpoint"`
+}
+
+func (c *Config) Validate() error {
+	if len(c.Probes) == 0 {
+		return errors.New("at least one probe must be configured")
+	}
+	probeNames := make(map[string]struct{}, len(c.Probes))
+	for i := range c.Probes {
+		p := &c.Probes[i]
+		if _, ok := probeNames[p.Name]; ok {
+			return fmt.Errorf("probes[%d]: duplicate probe name %q", i, p.Name)
+		}
+		probeNames[p.Name] = struct{}{}
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("probes[%d] %q: %w", i, p.Name, err)
+		}
+	}
+
+	if len(c.Services) == 0 {
+		return errors.New("at least one service must be configured")
+	}
+	serviceNames := make(map[string]struct{}, len(c.Services))
+	for i := range c.Services {
+		svc := &c.Services[i]
+		if _, ok := serviceNames[svc.Name]; ok {
+			return fmt.Errorf("services[%d]: duplicate service name %q", i, svc.Name)
+		}
+		serviceNames[svc.Name] = struct{}{}
+		for _, probeName := range svc.Probes {
+			if _, ok := probeNames[probeName]; !ok {
+				return fmt.Errorf("services[%d] %q: unknown probe %q", i, svc.Name, probeName)
+			}
+		}
+		if err := svc.Validate(len(c.Probes)); err != nil {
+			return fmt.Errorf("services[%d] %q: %w", i, svc.Name, err)
+		}
+	}
+	return nil
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"go.opentelemetry.io/collector/component"
@@ -13,19 +16,28 @@ import (
 	"google.golang.org/grpc/health"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
-)
 
-var (
-	client = http.Client{
-		Timeout: 5 * time.Second,
-	}
+	"github.com/jammymalina/otel-grpc-healthceck/probe"
 )
 
 type grpcHealthCheckExtension struct {
-	config   Config
-	logger   *zap.Logger
-	server   *grpc.Server
-	stopCh   chan struct{}
+	config Config
+	logger *zap.Logger
+	server *grpc.Server
+	hs     *health.Server
+
+	adminServer *http.Server
+	recheckChs  map[string]chan probe.RecheckRequest
+
+	mu              sync.Mutex
+	probeStatus     map[string]probe.Status
+	servicesByProbe map[string][]*ServiceConfig
+	lastReported    map[string]healthpb.HealthCheckResponse_ServingStatus
+
+	cancel context.CancelFunc
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+
 	settings component.TelemetrySettings
 }
 
@@ -44,30 +56,49 @@ func (gc *grpcHealthCheckExtension) Start(ctx context.Context, host component.Ho
 	}
 
 	gc.stopCh = make(chan struct{})
-	hs := health.NewServer()
+	gc.hs = health.NewServer()
 
 	// Register the health server with the gRPC server
-	healthpb.RegisterHealthServer(gc.server, hs)
+	healthpb.RegisterHealthServer(gc.server, gc.hs)
 	reflection.Register(gc.server)
 
-	go func() {
-		time.Sleep(gc.config.StartPeriod)
-
-		for {
-			status := healthpb.HealthCheckResponse_SERVING
-			response, err := client.Get(gc.config.HealthCheckHttpEndpoint)
-			if err != nil {
-				gc.logger.Error("Failed to get health check status", zap.Error(err))
-				status = healthpb.HealthCheckResponse_NOT_SERVING
-			} else if response.StatusCode < 200 || response.StatusCode >= 300 {
-				gc.logger.Error("Service seems to be unhealthy", zap.Int("code", response.StatusCode))
-				status = healthpb.HealthCheckResponse_NOT_SERVING
-			}
-			hs.SetServingStatus("", status)
-
-			time.Sleep(gc.config.Interval)
+	gc.buildDependencyGraph()
+
+	// health.NewServer() defaults the overall "" service to SERVING, which
+	// is misleading before any probe has run. Report every configured
+	// service as NOT_SERVING until the startup probes say otherwise.
+	for _, svc := range gc.config.Services {
+		gc.hs.SetServingStatus(svc.Name, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+
+	var probeCtx context.Context
+	probeCtx, gc.cancel = context.WithCancel(context.Background())
+
+	gc.recheckChs = make(map[string]chan probe.RecheckRequest, len(gc.config.Probes))
+	for _, cfg := range gc.config.Probes {
+		cfg := cfg
+		monitor, err := probe.NewMonitor(cfg, gc.logger, func(status probe.Status) {
+			gc.onProbeChange(cfg.Name, status)
+		})
+		if err != nil {
+			return fmt.Errorf("probe %q: %w", cfg.Name, err)
 		}
-	}()
+
+		recheckCh := make(chan probe.RecheckRequest)
+		gc.recheckChs[cfg.Name] = recheckCh
+
+		gc.wg.Add(1)
+		go func() {
+			defer gc.wg.Done()
+			monitor.Run(probeCtx, recheckCh)
+		}()
+	}
+
+	if gc.config.AdminEndpoint != "" {
+		if err := gc.startAdminServer(); err != nil {
+			return fmt.Errorf("failed to start admin server: %w", err)
+		}
+	}
 
 	go func() {
 		defer close(gc.stopCh)
@@ -81,14 +112,171 @@ func (gc *grpcHealthCheckExtension) Start(ctx context.Context, host component.Ho
 	return nil
 }
 
+// buildDependencyGraph indexes, for each probe name, the services whose
+// aggregated status depends on it. A service with no explicit Probes
+// depends on every configured probe.
+func (gc *grpcHealthCheckExtension) buildDependencyGraph() {
+	allProbeNames := make([]string, len(gc.config.Probes))
+	for i, p := range gc.config.Probes {
+		allProbeNames[i] = p.Name
+	}
+
+	gc.probeStatus = make(map[string]probe.Status, len(gc.config.Probes))
+	for _, name := range allProbeNames {
+		gc.probeStatus[name] = probe.StatusUnknown
+	}
+
+	gc.lastReported = make(map[string]healthpb.HealthCheckResponse_ServingStatus, len(gc.config.Services))
+	gc.servicesByProbe = make(map[string][]*ServiceConfig, len(gc.config.Probes))
+	for i := range gc.config.Services {
+		svc := &gc.config.Services[i]
+		gc.lastReported[svc.Name] = healthpb.HealthCheckResponse_NOT_SERVING
+
+		deps := svc.Probes
+		if len(deps) == 0 {
+			deps = allProbeNames
+		}
+		for _, name := range deps {
+			gc.servicesByProbe[name] = append(gc.servicesByProbe[name], svc)
+		}
+	}
+}
+
+// onProbeChange recomputes the aggregated status of every service that
+// depends on probeName and publishes it if it changed.
+func (gc *grpcHealthCheckExtension) onProbeChange(probeName string, status probe.Status) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	gc.probeStatus[probeName] = status
+
+	for _, svc := range gc.servicesByProbe[probeName] {
+		aggregated := healthpb.HealthCheckResponse_NOT_SERVING
+		if gc.aggregate(svc) == probe.StatusHealthy {
+			aggregated = healthpb.HealthCheckResponse_SERVING
+		}
+		if gc.lastReported[svc.Name] != aggregated {
+			gc.lastReported[svc.Name] = aggregated
+			gc.hs.SetServingStatus(svc.Name, aggregated)
+		}
+	}
+}
+
+// aggregate combines the current status of svc's dependency probes
+// according to its Policy. gc.mu must be held by the caller.
+func (gc *grpcHealthCheckExtension) aggregate(svc *ServiceConfig) probe.Status {
+	names := svc.Probes
+	if len(names) == 0 {
+		names = make([]string, 0, len(gc.probeStatus))
+		for _, p := range gc.config.Probes {
+			names = append(names, p.Name)
+		}
+	}
+
+	healthy := 0
+	for _, name := range names {
+		if gc.probeStatus[name] == probe.StatusHealthy {
+			healthy++
+		}
+	}
+
+	switch svc.Policy {
+	case PolicyAny:
+		if healthy > 0 {
+			return probe.StatusHealthy
+		}
+	case PolicyQuorum:
+		if healthy >= svc.Quorum {
+			return probe.StatusHealthy
+		}
+	default: // PolicyAll
+		if healthy == len(names) {
+			return probe.StatusHealthy
+		}
+	}
+	return probe.StatusUnhealthy
+}
+
+// startAdminServer serves POST /probes/{name}/check, which triggers an
+// immediate re-probe of the named probe and returns its resulting status.
+func (gc *grpcHealthCheckExtension) startAdminServer() error {
+	ln, err := net.Listen("tcp", gc.config.AdminEndpoint)
+	if err != nil {
+		return fmt.Errorf("failed to bind to address %s: %w", gc.config.AdminEndpoint, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/probes/", gc.handleRecheck)
+	gc.adminServer = &http.Server{Handler: mux}
+
+	go func() {
+		if err := gc.adminServer.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			gc.logger.Error("Admin server stopped unexpectedly", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+func (gc *grpcHealthCheckExtension) handleRecheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/probes/"), "/check")
+	recheckCh, ok := gc.recheckChs[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown probe %q", name), http.StatusNotFound)
+		return
+	}
+
+	req := probe.RecheckRequest{ResultCh: make(chan probe.Status, 1)}
+	select {
+	case recheckCh <- req:
+	case <-r.Context().Done():
+		return
+	}
+
+	select {
+	case status := <-req.ResultCh:
+		fmt.Fprintln(w, status.String())
+	case <-r.Context().Done():
+	}
+}
+
 func (gc *grpcHealthCheckExtension) Shutdown(context.Context) error {
 	if gc.server == nil {
 		return nil
 	}
+
+	// Flip every service to NOT_SERVING and stop accepting further
+	// SetServingStatus calls. From this point on probing is pointless -
+	// SetServingStatus is a silent no-op - so stop the probe loops right
+	// away instead of letting them keep dialing endpoints through the
+	// drain period and the GracefulStop wait.
+	if gc.hs != nil {
+		gc.hs.Shutdown()
+	}
+	if gc.cancel != nil {
+		gc.cancel()
+	}
+	gc.wg.Wait()
+
+	// Give load balancers and Watch() subscribers a chance to react before
+	// the listener actually closes.
+	if gc.config.DrainPeriod > 0 {
+		time.Sleep(gc.config.DrainPeriod)
+	}
+
 	gc.server.GracefulStop()
 	if gc.stopCh != nil {
 		<-gc.stopCh
 	}
+
+	if gc.adminServer != nil {
+		_ = gc.adminServer.Close()
+	}
+
 	return nil
 }
 
@@ -0,0 +1,108 @@
+package grpc_health_check
+
+import (
+	"testing"
+
+	"github.com/jammymalina/otel-grpc-healthceck/probe"
+)
+
+func TestAggregate(t *testing.T) {
+	cases := []struct {
+		name        string
+		allProbes   []string
+		probeStatus map[string]probe.Status
+		svc         ServiceConfig
+		want        probe.Status
+	}{
+		{
+			name:      "all: healthy only when every probe is healthy",
+			allProbes: []string{"a", "b"},
+			probeStatus: map[string]probe.Status{
+				"a": probe.StatusHealthy,
+				"b": probe.StatusHealthy,
+			},
+			svc:  ServiceConfig{Name: "svc", Probes: []string{"a", "b"}, Policy: PolicyAll},
+			want: probe.StatusHealthy,
+		},
+		{
+			name:      "all: unhealthy if one probe is unhealthy",
+			allProbes: []string{"a", "b"},
+			probeStatus: map[string]probe.Status{
+				"a": probe.StatusHealthy,
+				"b": probe.StatusUnhealthy,
+			},
+			svc:  ServiceConfig{Name: "svc", Probes: []string{"a", "b"}, Policy: PolicyAll},
+			want: probe.StatusUnhealthy,
+		},
+		{
+			name:      "any: healthy if at least one probe is healthy",
+			allProbes: []string{"a", "b"},
+			probeStatus: map[string]probe.Status{
+				"a": probe.StatusUnhealthy,
+				"b": probe.StatusHealthy,
+			},
+			svc:  ServiceConfig{Name: "svc", Probes: []string{"a", "b"}, Policy: PolicyAny},
+			want: probe.StatusHealthy,
+		},
+		{
+			name:      "any: unhealthy if no probe is healthy",
+			allProbes: []string{"a", "b"},
+			probeStatus: map[string]probe.Status{
+				"a": probe.StatusUnhealthy,
+				"b": probe.StatusUnknown,
+			},
+			svc:  ServiceConfig{Name: "svc", Probes: []string{"a", "b"}, Policy: PolicyAny},
+			want: probe.StatusUnhealthy,
+		},
+		{
+			name:      "quorum: healthy once enough probes are healthy",
+			allProbes: []string{"a", "b", "c"},
+			probeStatus: map[string]probe.Status{
+				"a": probe.StatusHealthy,
+				"b": probe.StatusHealthy,
+				"c": probe.StatusUnhealthy,
+			},
+			svc:  ServiceConfig{Name: "svc", Probes: []string{"a", "b", "c"}, Policy: PolicyQuorum, Quorum: 2},
+			want: probe.StatusHealthy,
+		},
+		{
+			name:      "quorum: unhealthy below the quorum",
+			allProbes: []string{"a", "b", "c"},
+			probeStatus: map[string]probe.Status{
+				"a": probe.StatusHealthy,
+				"b": probe.StatusUnhealthy,
+				"c": probe.StatusUnhealthy,
+			},
+			svc:  ServiceConfig{Name: "svc", Probes: []string{"a", "b", "c"}, Policy: PolicyQuorum, Quorum: 2},
+			want: probe.StatusUnhealthy,
+		},
+		{
+			name:      "empty Probes aggregates every configured probe",
+			allProbes: []string{"a", "b"},
+			probeStatus: map[string]probe.Status{
+				"a": probe.StatusHealthy,
+				"b": probe.StatusHealthy,
+			},
+			svc:  ServiceConfig{Name: "", Policy: PolicyAll},
+			want: probe.StatusHealthy,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			probes := make([]probe.Config, len(tc.allProbes))
+			for i, name := range tc.allProbes {
+				probes[i] = probe.Config{Name: name}
+			}
+
+			gc := &grpcHealthCheckExtension{
+				config:      Config{Probes: probes},
+				probeStatus: tc.probeStatus,
+			}
+
+			if got := gc.aggregate(&tc.svc); got != tc.want {
+				t.Errorf("aggregate() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,403 @@
+// Package probe implements the individual health checks (HTTP, TCP, gRPC,
+// exec) that back a service in the grpc_health_check extension, along with
+// the polling loop that turns repeated probe results into a debounced
+// Status.
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Type identifies the mechanism used to determine whether an upstream
+// dependency is healthy.
+type Type string
+
+const (
+	// TypeHTTP issues an HTTP GET against Endpoint and treats any 2xx
+	// response as healthy.
+	TypeHTTP Type = "http"
+	// TypeTCP dials Endpoint and treats a successful connection as healthy.
+	TypeTCP Type = "tcp"
+	// TypeGRPC calls grpc.health.v1.Health/Check against a downstream gRPC
+	// server and treats a SERVING response as healthy.
+	TypeGRPC Type = "grpc"
+	// TypeExec runs a local command and treats a zero exit code as healthy.
+	TypeExec Type = "exec"
+)
+
+// HTTPConfig configures a TypeHTTP probe.
+type HTTPConfig struct {
+	Endpoint string `mapstructure:"endpoint"`
+}
+
+func (c *HTTPConfig) Validate() error {
+	if c == nil || c.Endpoint == "" {
+		return errors.New("http.endpoint must be set")
+	}
+	return nil
+}
+
+// TCPConfig configures a TypeTCP probe.
+type TCPConfig struct {
+	Endpoint string `mapstructure:"endpoint"`
+}
+
+func (c *TCPConfig) Validate() error {
+	if c == nil || c.Endpoint == "" {
+		return errors.New("tcp.endpoint must be set")
+	}
+	return nil
+}
+
+// GRPCConfig configures a TypeGRPC probe.
+type GRPCConfig struct {
+	Endpoint string `mapstructure:"endpoint"`
+	// Service is the service name passed to the downstream Check call. The
+	// empty string checks the overall server status.
+	Service string `mapstructure:"service"`
+}
+
+func (c *GRPCConfig) Validate() error {
+	if c == nil || c.Endpoint == "" {
+		return errors.New("grpc.endpoint must be set")
+	}
+	return nil
+}
+
+// ExecConfig configures a TypeExec probe.
+type ExecConfig struct {
+	Command []string `mapstructure:"command"`
+}
+
+func (c *ExecConfig) Validate() error {
+	if c == nil || len(c.Command) == 0 {
+		return errors.New("exec.command must not be empty")
+	}
+	return nil
+}
+
+// Config describes a single named probe.
+type Config struct {
+	// Name identifies this probe so that services can depend on it.
+	Name string `mapstructure:"name"`
+
+	Type Type `mapstructure:"type"`
+
+	HTTP *HTTPConfig `mapstructure:"http"`
+	TCP  *TCPConfig  `mapstructure:"tcp"`
+	GRPC *GRPCConfig `mapstructure:"grpc"`
+	Exec *ExecConfig `mapstructure:"exec"`
+
+	// Interval is the time between probes once the start period has
+	// elapsed.
+	Interval time.Duration `mapstructure:"interval"`
+	// Timeout bounds a single probe attempt.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// StartPeriod is the longest the faster startup probe loop runs after
+	// Start. The loop ends early on the first healthy result; otherwise it
+	// falls back to the steady-state Interval once StartPeriod elapses,
+	// regardless of outcome.
+	StartPeriod time.Duration `mapstructure:"start_period"`
+	// StartupInterval is the probe cadence during StartPeriod, typically
+	// much shorter than Interval so a slow-starting dependency is picked up
+	// quickly. Defaults to Interval.
+	StartupInterval time.Duration `mapstructure:"startup_interval"`
+
+	// HealthyThreshold is the number of consecutive successful probes
+	// required to transition from unhealthy to healthy. Defaults to 1.
+	HealthyThreshold int `mapstructure:"healthy_threshold"`
+	// UnhealthyThreshold is the number of consecutive failed probes
+	// required to transition from healthy to unhealthy. Defaults to 1.
+	UnhealthyThreshold int `mapstructure:"unhealthy_threshold"`
+}
+
+func (c *Config) Validate() error {
+	if c.Name == "" {
+		return errors.New("name must be set")
+	}
+	switch c.Type {
+	case TypeHTTP:
+		if err := c.HTTP.Validate(); err != nil {
+			return err
+		}
+	case TypeTCP:
+		if err := c.TCP.Validate(); err != nil {
+			return err
+		}
+	case TypeGRPC:
+		if err := c.GRPC.Validate(); err != nil {
+			return err
+		}
+	case TypeExec:
+		if err := c.Exec.Validate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown probe type %q", c.Type)
+	}
+	if c.Interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	if c.Timeout <= 0 {
+		return errors.New("timeout must be positive")
+	}
+	if c.StartupInterval < 0 {
+		return errors.New("startup_interval must not be negative")
+	}
+	if c.HealthyThreshold < 0 {
+		return errors.New("healthy_threshold must not be negative")
+	}
+	if c.UnhealthyThreshold < 0 {
+		return errors.New("unhealthy_threshold must not be negative")
+	}
+	return nil
+}
+
+// Prober checks the health of a single upstream dependency.
+type Prober interface {
+	// Probe runs one health check and returns nil if healthy, or an error
+	// describing why it isn't.
+	Probe(ctx context.Context) error
+}
+
+// New builds the Prober described by cfg.
+func New(cfg Config) (Prober, error) {
+	switch cfg.Type {
+	case TypeHTTP:
+		return &httpProbe{endpoint: cfg.HTTP.Endpoint}, nil
+	case TypeTCP:
+		return &tcpProbe{endpoint: cfg.TCP.Endpoint}, nil
+	case TypeGRPC:
+		conn, err := grpc.NewClient(cfg.GRPC.Endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to dial %s: %w", cfg.GRPC.Endpoint, err)
+		}
+		return &grpcProbe{conn: conn, service: cfg.GRPC.Service}, nil
+	case TypeExec:
+		return &execProbe{command: cfg.Exec.Command}, nil
+	default:
+		return nil, fmt.Errorf("unknown probe type %q", cfg.Type)
+	}
+}
+
+type httpProbe struct {
+	endpoint string
+}
+
+func (p *httpProbe) Probe(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unhealthy status code %d", response.StatusCode)
+	}
+	return nil
+}
+
+type tcpProbe struct {
+	endpoint string
+}
+
+func (p *tcpProbe) Probe(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", p.endpoint)
+	if err != nil {
+		return fmt.Errorf("dial failed: %w", err)
+	}
+	return conn.Close()
+}
+
+// grpcProbe keeps a single ClientConn for the lifetime of the Monitor so
+// that each tick reuses the existing connection (and any in-flight
+// reconnects) rather than paying for a fresh handshake every Interval.
+type grpcProbe struct {
+	conn    *grpc.ClientConn
+	service string
+}
+
+func (p *grpcProbe) Probe(ctx context.Context) error {
+	resp, err := healthpb.NewHealthClient(p.conn).Check(ctx, &healthpb.HealthCheckRequest{Service: p.service})
+	if err != nil {
+		return fmt.Errorf("check failed: %w", err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("upstream reported status %s", resp.Status)
+	}
+	return nil
+}
+
+func (p *grpcProbe) Close() error {
+	return p.conn.Close()
+}
+
+type execProbe struct {
+	command []string
+}
+
+func (p *execProbe) Probe(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, p.command[0], p.command[1:]...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("command failed: %w (output: %s)", err, out)
+	}
+	return nil
+}
+
+// Status is the debounced health of a single probe.
+type Status int
+
+const (
+	// StatusUnknown is the status before the first threshold-satisfying
+	// run has completed.
+	StatusUnknown Status = iota
+	StatusHealthy
+	StatusUnhealthy
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusHealthy:
+		return "healthy"
+	case StatusUnhealthy:
+		return "unhealthy"
+	default:
+		return "unknown"
+	}
+}
+
+// RecheckRequest asks a running Monitor to run an out-of-band probe right
+// now and report back the resulting Status.
+type RecheckRequest struct {
+	ResultCh chan Status
+}
+
+// Monitor repeatedly runs a Prober on Config's schedule, debounces the
+// result through the configured thresholds, and reports changes via
+// onChange.
+type Monitor struct {
+	cfg      Config
+	prober   Prober
+	logger   *zap.Logger
+	onChange func(Status)
+}
+
+// NewMonitor builds a Monitor for cfg.
+func NewMonitor(cfg Config, logger *zap.Logger, onChange func(Status)) (*Monitor, error) {
+	p, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Monitor{cfg: cfg, prober: p, logger: logger, onChange: onChange}, nil
+}
+
+// Run blocks until ctx is canceled. While cfg.StartPeriod hasn't elapsed and
+// the probe hasn't yet succeeded, it probes at the faster cfg.StartupInterval
+// cadence; afterwards it falls back to the steady-state cfg.Interval
+// regardless of outcome. RecheckCh lets callers request an immediate
+// out-of-band probe.
+func (m *Monitor) Run(ctx context.Context, recheckCh <-chan RecheckRequest) {
+	if closer, ok := m.prober.(interface{ Close() error }); ok {
+		defer closer.Close()
+	}
+
+	healthyThreshold := m.cfg.HealthyThreshold
+	if healthyThreshold == 0 {
+		healthyThreshold = 1
+	}
+	unhealthyThreshold := m.cfg.UnhealthyThreshold
+	if unhealthyThreshold == 0 {
+		unhealthyThreshold = 1
+	}
+
+	current := StatusUnknown
+	consecutiveSuccesses, consecutiveFailures := 0, 0
+
+	runOnce := func() Status {
+		probeCtx, cancel := context.WithTimeout(ctx, m.cfg.Timeout)
+		err := m.prober.Probe(probeCtx)
+		cancel()
+
+		if err != nil {
+			m.logger.Error("Probe failed", zap.String("probe", m.cfg.Name), zap.Error(err))
+			consecutiveFailures++
+			consecutiveSuccesses = 0
+		} else {
+			consecutiveSuccesses++
+			consecutiveFailures = 0
+		}
+
+		switch {
+		case current != StatusHealthy && consecutiveSuccesses >= healthyThreshold:
+			current = StatusHealthy
+			m.onChange(current)
+		case current != StatusUnhealthy && consecutiveFailures >= unhealthyThreshold:
+			current = StatusUnhealthy
+			m.onChange(current)
+		}
+		return current
+	}
+
+	if m.cfg.StartPeriod > 0 {
+		startupInterval := m.cfg.StartupInterval
+		if startupInterval <= 0 {
+			startupInterval = m.cfg.Interval
+		}
+
+		deadline := time.NewTimer(m.cfg.StartPeriod)
+		startupTicker := time.NewTicker(startupInterval)
+
+	startup:
+		for {
+			select {
+			case <-ctx.Done():
+				deadline.Stop()
+				startupTicker.Stop()
+				return
+			case <-deadline.C:
+				break startup
+			case <-startupTicker.C:
+				if runOnce() == StatusHealthy {
+					break startup
+				}
+			case req := <-recheckCh:
+				status := runOnce()
+				req.ResultCh <- status
+				if status == StatusHealthy {
+					break startup
+				}
+			}
+		}
+		deadline.Stop()
+		startupTicker.Stop()
+	}
+
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-recheckCh:
+			req.ResultCh <- runOnce()
+		case <-ticker.C:
+			runOnce()
+		}
+	}
+}
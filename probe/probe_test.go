@@ -0,0 +1,106 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeProber returns the next error from results on each call, repeating the
+// last entry once exhausted.
+type fakeProber struct {
+	mu      sync.Mutex
+	results []error
+	calls   int
+}
+
+func (f *fakeProber) Probe(context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	idx := f.calls
+	if idx >= len(f.results) {
+		idx = len(f.results) - 1
+	}
+	f.calls++
+	return f.results[idx]
+}
+
+func recvStatus(t *testing.T, ch <-chan Status, timeout time.Duration) Status {
+	t.Helper()
+	select {
+	case s := <-ch:
+		return s
+	case <-time.After(timeout):
+		t.Fatal("timed out waiting for a status change")
+		return StatusUnknown
+	}
+}
+
+func TestMonitorRunDebouncesOnThreshold(t *testing.T) {
+	fail := errors.New("unhealthy")
+	prober := &fakeProber{results: []error{fail, fail, nil, nil, nil, fail, fail, fail}}
+
+	cfg := Config{
+		Name:               "test",
+		Interval:           5 * time.Millisecond,
+		Timeout:            50 * time.Millisecond,
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 3,
+	}
+
+	changes := make(chan Status, 10)
+	m := &Monitor{cfg: cfg, prober: prober, logger: zap.NewNop(), onChange: func(s Status) { changes <- s }}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx, make(chan RecheckRequest))
+
+	if got := recvStatus(t, changes, time.Second); got != StatusHealthy {
+		t.Fatalf("first transition = %v, want StatusHealthy", got)
+	}
+	if got := recvStatus(t, changes, time.Second); got != StatusUnhealthy {
+		t.Fatalf("second transition = %v, want StatusUnhealthy", got)
+	}
+}
+
+func TestMonitorRunUsesStartupIntervalDuringStartPeriod(t *testing.T) {
+	fail := errors.New("not ready yet")
+	// Three failures at the fast StartupInterval cadence, then success.
+	prober := &fakeProber{results: []error{fail, fail, fail, nil}}
+
+	cfg := Config{
+		Name:     "test",
+		Interval: 500 * time.Millisecond, // steady-state: far too slow to explain a quick success
+		Timeout:  50 * time.Millisecond,
+
+		StartPeriod:     200 * time.Millisecond,
+		StartupInterval: 10 * time.Millisecond,
+
+		HealthyThreshold: 1,
+		// Higher than the number of startup failures in the fixture, so
+		// none of them trip an intermediate StatusUnhealthy transition
+		// before the eventual success fires StatusHealthy.
+		UnhealthyThreshold: 10,
+	}
+
+	changes := make(chan Status, 10)
+	m := &Monitor{cfg: cfg, prober: prober, logger: zap.NewNop(), onChange: func(s Status) { changes <- s }}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := time.Now()
+	go m.Run(ctx, make(chan RecheckRequest))
+
+	if got := recvStatus(t, changes, time.Second); got != StatusHealthy {
+		t.Fatalf("transition = %v, want StatusHealthy", got)
+	}
+	if elapsed := time.Since(start); elapsed >= cfg.Interval {
+		t.Fatalf("became healthy after %v, which only the steady-state Interval (not the faster StartupInterval) could explain", elapsed)
+	}
+}